@@ -0,0 +1,223 @@
+package main
+
+// Implementação do algoritmo de Bully (Garcia-Molina), selecionável via
+// ELECTION_ALGO=bully como alternativa ao anel com COOR já existente em
+// iniciarEleicao/eleicaoHandler. Ao suspeitar do líder, o processo manda
+// BULLY_ELECTION só para os peers de ID maior; se nenhum responder
+// BULLY_ALIVE dentro de bullyTimeout, ele se declara líder e avisa todos os
+// de ID menor com COOR (reaproveitando o mesmo Tipo/handler do anel).
+// O(1) rodadas em rede estável, contra o O(N) da propagação em anel.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const bullyTimeout = 3 * time.Second
+
+var (
+	bullyMutex        sync.Mutex
+	bullyEleicaoAtiva bool
+	bullyAliveCh      chan struct{}
+	// bullyCoordCh é sinalizado por coordinatorHandler quando o COOR do novo
+	// líder chega, destravando aguardarCoordenadorBully. Sem isso, um
+	// processo que recebeu ALIVE mas cujo superior morre antes de anunciar o
+	// COOR ficaria esperando para sempre.
+	bullyCoordCh chan struct{}
+)
+
+// arquivoLider é onde liderAtual é persistido entre reinícios, no mesmo
+// espírito do raft_state_<id>.json do pacote raft.
+func arquivoLider() string {
+	return fmt.Sprintf("lider_state_%d.json", meuID)
+}
+
+type liderPersistido struct {
+	LiderID int `json:"lider_id"`
+}
+
+// carregarLiderPersistido lê o último líder conhecido do disco; ausência do
+// arquivo (primeira execução) não é um erro.
+func carregarLiderPersistido() {
+	data, err := os.ReadFile(arquivoLider())
+	if err != nil {
+		return
+	}
+	var st liderPersistido
+	if err := json.Unmarshal(data, &st); err != nil {
+		fmt.Printf("[BULLY] erro ao carregar líder persistido: %v\n", err)
+		return
+	}
+	mutex.Lock()
+	liderAtual = st.LiderID
+	mutex.Unlock()
+}
+
+// persistirLider grava liderAtual em disco para sobreviver a reinícios.
+func persistirLider(liderID int) {
+	data, err := json.Marshal(liderPersistido{LiderID: liderID})
+	if err != nil {
+		fmt.Printf("[BULLY] erro ao serializar líder: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(arquivoLider(), data, 0o644); err != nil {
+		fmt.Printf("[BULLY] erro ao persistir líder em %s: %v\n", arquivoLider(), err)
+	}
+}
+
+// iniciarEleicaoBully manda BULLY_ELECTION para todo peer de ID maior e
+// aguarda até bullyTimeout por um BULLY_ALIVE. Se ninguém maior responder,
+// este processo se declara líder.
+func iniciarEleicaoBully() {
+	bullyMutex.Lock()
+	if bullyEleicaoAtiva {
+		bullyMutex.Unlock()
+		return
+	}
+	bullyEleicaoAtiva = true
+	bullyAliveCh = make(chan struct{}, numProcessos)
+	bullyCoordCh = make(chan struct{}, 1)
+	bullyMutex.Unlock()
+
+	eleicoesIniciadas.Inc()
+	fmt.Printf("\n[BULLY] Processo %d iniciou a eleição\n", meuID)
+
+	haSuperior := false
+	for candidato := meuID + 1; candidato <= numProcessos; candidato++ {
+		haSuperior = true
+		go func(id int) {
+			msg := Mensagem{
+				Tipo:      "BULLY_ELECTION",
+				OrigemID:  meuID,
+				ProcessID: meuID,
+				Timestamp: incrementarRelogio(),
+			}
+			if err := transporte.Send(context.Background(), enderecoTransporte(id), msg); err != nil {
+				fmt.Printf("ERRO: Falha ao enviar BULLY_ELECTION para %s: %v\n", enderecoTransporte(id), err)
+			}
+		}(candidato)
+	}
+
+	if !haSuperior {
+		declararLiderBully()
+		return
+	}
+
+	select {
+	case <-bullyAliveCh:
+		fmt.Printf("[BULLY] Processo %d recebeu ALIVE; aguardando novo coordenador.\n", meuID)
+		bullyMutex.Lock()
+		bullyEleicaoAtiva = false
+		bullyMutex.Unlock()
+		aguardarCoordenadorBully()
+	case <-time.After(bullyTimeout):
+		declararLiderBully()
+	}
+}
+
+// aguardarCoordenadorBully espera até bullyTimeout pelo COOR do superior que
+// respondeu ALIVE (sinalizado por notificarCoordenadorBully, chamada de
+// coordinatorHandler). Se o superior morrer nesse meio tempo e o COOR nunca
+// chegar, uma nova rodada de eleição é disparada em vez de esperar para
+// sempre.
+func aguardarCoordenadorBully() {
+	select {
+	case <-bullyCoordCh:
+		fmt.Printf("[BULLY] Processo %d confirmou o novo coordenador.\n", meuID)
+	case <-time.After(bullyTimeout):
+		fmt.Printf("[BULLY] Processo %d não recebeu COOR a tempo; tentando nova eleição.\n", meuID)
+		iniciarEleicaoBully()
+	}
+}
+
+// notificarCoordenadorBully destrava aguardarCoordenadorBully quando um COOR
+// chega (via coordinatorHandler); não faz nada se ninguém estiver esperando.
+func notificarCoordenadorBully() {
+	bullyMutex.Lock()
+	ch := bullyCoordCh
+	bullyMutex.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// declararLiderBully é chamado quando nenhum processo de ID maior respondeu
+// a tempo: este processo se torna líder e avisa todos os de ID menor.
+func declararLiderBully() {
+	mutex.Lock()
+	liderAtual = meuID
+	eleicaoAtiva = false
+	mutex.Unlock()
+	persistirLider(meuID)
+
+	bullyMutex.Lock()
+	bullyEleicaoAtiva = false
+	bullyMutex.Unlock()
+
+	eleicoesConcluidas.Inc()
+	fmt.Printf("\n[BULLY] Processo %d se declara líder.\n\n", meuID)
+
+	for candidato := 1; candidato < meuID; candidato++ {
+		go func(id int) {
+			msg := Mensagem{
+				Tipo:      "COOR",
+				ProcessID: meuID,
+				Timestamp: incrementarRelogio(),
+			}
+			if err := transporte.Send(context.Background(), enderecoTransporte(id), msg); err != nil {
+				fmt.Printf("ERRO: Falha ao anunciar COOR (bully) para %s: %v\n", enderecoTransporte(id), err)
+			}
+		}(candidato)
+	}
+
+	tentarRegenerarTokenSeLider()
+}
+
+// bullyElectionHandler (via Transport) atende BULLY_ELECTION: responde
+// ALIVE ao remetente (que tem ID menor) e inicia sua própria eleição, como
+// manda o algoritmo de Bully.
+func bullyElectionHandler(msg Mensagem) (Mensagem, error) {
+	atualizarRelogio(msg.Timestamp)
+	fmt.Printf("[BULLY] ELECTION recebida do processo %d (menor). Respondendo ALIVE.\n", msg.OrigemID)
+
+	origem := msg.OrigemID
+	go func() {
+		aliveMsg := Mensagem{
+			Tipo:      "BULLY_ALIVE",
+			ProcessID: meuID,
+			Timestamp: incrementarRelogio(),
+		}
+		if err := transporte.Send(context.Background(), enderecoTransporte(origem), aliveMsg); err != nil {
+			fmt.Printf("ERRO: Falha ao enviar BULLY_ALIVE para %s: %v\n", enderecoTransporte(origem), err)
+		}
+	}()
+
+	go iniciarEleicaoBully()
+
+	return Mensagem{Tipo: "BULLY_ELECTION_OK"}, nil
+}
+
+// bullyAliveHandler (via Transport) recebe BULLY_ALIVE de um peer de ID
+// maior, destravando a espera em iniciarEleicaoBully.
+func bullyAliveHandler(msg Mensagem) (Mensagem, error) {
+	atualizarRelogio(msg.Timestamp)
+
+	bullyMutex.Lock()
+	if bullyEleicaoAtiva && bullyAliveCh != nil {
+		select {
+		case bullyAliveCh <- struct{}{}:
+		default:
+		}
+	}
+	bullyMutex.Unlock()
+
+	return Mensagem{Tipo: "BULLY_ALIVE_OK"}, nil
+}
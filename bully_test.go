@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// TestNotificarCoordenadorBullyDestravaEspera garante que um COOR que chega
+// enquanto aguardarCoordenadorBully está esperando destrava a espera pelo
+// canal, em vez de só expirar pelo timeout (que dispararia uma eleição
+// desnecessária).
+func TestNotificarCoordenadorBullyDestravaEspera(t *testing.T) {
+	bullyMutex.Lock()
+	bullyCoordCh = make(chan struct{}, 1)
+	bullyMutex.Unlock()
+
+	notificarCoordenadorBully()
+
+	select {
+	case <-bullyCoordCh:
+		// OK: o sinal já estava disponível, como aguardarCoordenadorBully esperaria.
+	default:
+		t.Fatal("notificarCoordenadorBully não sinalizou bullyCoordCh")
+	}
+}
+
+// TestNotificarCoordenadorBullySemEsperaNaoBloqueia garante que notificar
+// sem que ninguém esteja esperando (bullyCoordCh nil) não trava nem entra em
+// pânico.
+func TestNotificarCoordenadorBullySemEsperaNaoBloqueia(t *testing.T) {
+	bullyMutex.Lock()
+	bullyCoordCh = nil
+	bullyMutex.Unlock()
+
+	notificarCoordenadorBully()
+}
@@ -0,0 +1,166 @@
+package main
+
+// Entrega causal via relógios vetoriais (CLOCK_MODE=vector), selecionável
+// como alternativa à ordenação total com ACKs da Q1 (dispararMulticast/
+// receiveMessageInternal). Exige menos coordenação — nenhum ACK, nenhuma
+// fila com contagem de confirmações — ao custo de uma garantia mais fraca:
+// só ordena mensagens causalmente relacionadas, não todas entre si.
+//
+// Predicado de entrega (Vm = relógio da mensagem m, vindo do processo j):
+//
+//	(a) Vm[j]  == vetorLocal[j] + 1   -- é a próxima mensagem esperada de j
+//	(b) Vm[k]  <= vetorLocal[k]       -- para todo k != j, já vimos tudo que
+//	                                     m viu antes de ser enviada
+//
+// Quando ambas valem, a mensagem é entregue, vetorLocal[j] é incrementado e
+// o buffer é reexaminado, pois a entrega pode ter liberado outra mensagem.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrClockModeInativo é devolvido pelos endpoints causais quando
+// CLOCK_MODE != vector.
+var ErrClockModeInativo = errors.New("CLOCK_MODE=vector não está ativo")
+
+var (
+	vectorMutex sync.Mutex
+	// vetorLocal é o relógio vetorial deste processo; vetorLocal[i] é o
+	// componente do processo i+1 (ProcessID 1-based, índice 0-based).
+	vetorLocal []int
+	// bufferCausal guarda mensagens recebidas fora de ordem causal,
+	// aguardando que o predicado de entrega seja satisfeito.
+	bufferCausal []Mensagem
+)
+
+// causalPronta avalia o predicado de entrega para a mensagem com relógio vm
+// vinda do processo de índice j (0-based), contra vetorLocal. Chamada com
+// vectorMutex já travado.
+func causalPronta(vm []int, j int) bool {
+	if j < 0 || j >= len(vetorLocal) || len(vm) != len(vetorLocal) {
+		return false
+	}
+	if vm[j] != vetorLocal[j]+1 {
+		return false
+	}
+	for k := range vm {
+		if k == j {
+			continue
+		}
+		if vm[k] > vetorLocal[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// entregarCausaisProntas percorre bufferCausal entregando, em qualquer
+// ordem, toda mensagem cujo predicado já esteja satisfeito, repetindo até
+// não sobrar nenhuma pronta (a entrega de uma pode liberar a próxima do
+// mesmo processo). Chamada com vectorMutex já travado.
+func entregarCausaisProntas() {
+	for {
+		entregue := false
+		for i, m := range bufferCausal {
+			j := m.ProcessID - 1
+			if !causalPronta(m.VectorClock, j) {
+				continue
+			}
+			fmt.Printf("[CAUSAL ENTREGUE] Processo %d, Conteúdo: %s, Vetor: %v\n", m.ProcessID, m.Conteudo, m.VectorClock)
+			vetorLocal[j]++
+			bufferCausal = append(bufferCausal[:i], bufferCausal[i+1:]...)
+			entregue = true
+			break
+		}
+		if !entregue {
+			return
+		}
+	}
+}
+
+// receiveCausalInternal buffera msg e tenta entregar respeitando o
+// predicado de causalidade.
+func receiveCausalInternal(msg Mensagem) {
+	vectorMutex.Lock()
+	defer vectorMutex.Unlock()
+	bufferCausal = append(bufferCausal, msg)
+	entregarCausaisProntas()
+}
+
+// causalMessageHandler (via Transport) recebe uma CAUSAL_MSG de outro
+// processo e a entrega ao predicado causal.
+func causalMessageHandler(msg Mensagem) (Mensagem, error) {
+	if clockMode != "vector" {
+		return Mensagem{}, ErrClockModeInativo
+	}
+	receiveCausalInternal(msg)
+	return Mensagem{Tipo: "CAUSAL_MSG_OK"}, nil
+}
+
+// causalSend é o handler do usuário para disparar uma mensagem causal
+// (POST /causal_send): incrementa o próprio componente do vetor, entrega
+// localmente (uma mensagem sempre é causalmente pronta para quem a envia) e
+// propaga aos peers.
+func causalSend(c *gin.Context) {
+	if clockMode != "vector" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrClockModeInativo.Error()})
+		return
+	}
+
+	var req struct {
+		Conteudo string `json:"conteudo"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'conteudo' in request"})
+		return
+	}
+
+	vectorMutex.Lock()
+	vetorLocal[meuID-1]++
+	vetor := make([]int, len(vetorLocal))
+	copy(vetor, vetorLocal)
+	vectorMutex.Unlock()
+
+	msg := Mensagem{
+		Conteudo:    req.Conteudo,
+		ProcessID:   meuID,
+		Tipo:        "CAUSAL_MSG",
+		VectorClock: vetor,
+	}
+
+	// A própria mensagem já satisfaz o predicado de entrega para quem a
+	// enviou (vetorLocal[meuID-1] acabou de ser incrementado para refletir
+	// isso), então ela é "entregue" aqui mesmo, sem passar pelo buffer.
+	fmt.Printf("[CAUSAL ENTREGUE] Processo %d, Conteúdo: %s, Vetor: %v\n", msg.ProcessID, msg.Conteudo, msg.VectorClock)
+
+	for id := 1; id <= numProcessos; id++ {
+		if id == meuID {
+			continue
+		}
+		go func(destino int) {
+			addr := enderecoTransporte(destino)
+			fmt.Printf("-> Enviando CAUSAL_MSG para %s: Conteúdo: '%s' Vetor: %v\n", addr, msg.Conteudo, msg.VectorClock)
+			if err := transporte.Send(context.Background(), addr, msg); err != nil {
+				fmt.Printf("ERRO: Falha ao enviar CAUSAL_MSG para %s: %v\n", addr, err)
+			}
+		}(id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "causal_enviada", "message": msg})
+}
+
+// clockHandler (GET /clock) expõe o relógio vetorial atual deste processo.
+func clockHandler(c *gin.Context) {
+	vectorMutex.Lock()
+	vetor := make([]int, len(vetorLocal))
+	copy(vetor, vetorLocal)
+	vectorMutex.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{"process_id": meuID, "vetor": vetor})
+}
@@ -0,0 +1,102 @@
+package main
+
+import "testing"
+
+func TestCausalPronta(t *testing.T) {
+	casos := []struct {
+		nome       string
+		vetorLocal []int
+		vm         []int
+		j          int
+		quer       bool
+	}{
+		{
+			nome:       "próxima mensagem esperada do processo j, sem mensagens pendentes de outros",
+			vetorLocal: []int{0, 0, 0},
+			vm:         []int{0, 1, 0},
+			j:          1,
+			quer:       true,
+		},
+		{
+			nome:       "mensagem repetida de j (já entregue) não está pronta",
+			vetorLocal: []int{0, 1, 0},
+			vm:         []int{0, 1, 0},
+			j:          1,
+			quer:       false,
+		},
+		{
+			nome:       "mensagem futura de j (pulou uma) não está pronta",
+			vetorLocal: []int{0, 0, 0},
+			vm:         []int{0, 2, 0},
+			j:          1,
+			quer:       false,
+		},
+		{
+			nome:       "depende de uma mensagem de outro processo ainda não vista",
+			vetorLocal: []int{0, 0, 0},
+			vm:         []int{1, 1, 0},
+			j:          1,
+			quer:       false,
+		},
+		{
+			nome:       "j fora dos limites do vetor é rejeitado",
+			vetorLocal: []int{0, 0, 0},
+			vm:         []int{0, 1, 0},
+			j:          5,
+			quer:       false,
+		},
+		{
+			nome:       "vetor da mensagem com tamanho diferente é rejeitado",
+			vetorLocal: []int{0, 0, 0},
+			vm:         []int{0, 1},
+			j:          1,
+			quer:       false,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			vetorLocal = append([]int(nil), c.vetorLocal...)
+			if got := causalPronta(c.vm, c.j); got != c.quer {
+				t.Fatalf("causalPronta(%v, %d) com vetorLocal=%v = %v, quer %v", c.vm, c.j, c.vetorLocal, got, c.quer)
+			}
+		})
+	}
+}
+
+func TestEntregarCausaisProntas(t *testing.T) {
+	// Processo 2 (j=1) manda duas mensagens fora de ordem; a segunda só
+	// pode ser entregue depois da primeira liberar vetorLocal[1].
+	vetorLocal = []int{0, 0, 0}
+	bufferCausal = []Mensagem{
+		{ProcessID: 2, Conteudo: "segunda", VectorClock: []int{0, 2, 0}},
+		{ProcessID: 2, Conteudo: "primeira", VectorClock: []int{0, 1, 0}},
+	}
+
+	entregarCausaisProntas()
+
+	if len(bufferCausal) != 0 {
+		t.Fatalf("bufferCausal deveria estar vazio após entregar em cascata, restou %+v", bufferCausal)
+	}
+	if vetorLocal[1] != 2 {
+		t.Fatalf("vetorLocal[1] = %d, quer 2 (as duas mensagens de j=1 deveriam ter sido entregues)", vetorLocal[1])
+	}
+}
+
+func TestEntregarCausaisProntasMantemPendentes(t *testing.T) {
+	// Mensagem de j=1 depende de uma mensagem de j=0 que ainda não chegou;
+	// deve continuar no buffer em vez de ser entregue fora de ordem causal.
+	vetorLocal = []int{0, 0, 0}
+	bufferCausal = []Mensagem{
+		{ProcessID: 2, Conteudo: "depende de outro processo", VectorClock: []int{1, 1, 0}},
+	}
+
+	entregarCausaisProntas()
+
+	if len(bufferCausal) != 1 {
+		t.Fatalf("bufferCausal deveria manter a mensagem pendente, tem %d entradas", len(bufferCausal))
+	}
+	if vetorLocal[1] != 0 {
+		t.Fatalf("vetorLocal[1] = %d, não deveria ter avançado sem a causa satisfeita", vetorLocal[1])
+	}
+}
@@ -0,0 +1,569 @@
+// Package raft implementa um protocolo de replicação de log (Raft) que
+// reaproveita o transporte HTTP/Gin já usado pelo resto do projeto. É uma
+// alternativa à ordenação total por relógio de Lamport (Q1): em vez de
+// contar ACKs, as mensagens só são entregues depois de confirmadas em log
+// replicado por maioria.
+package raft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	heartbeatInterval  = 50 * time.Millisecond
+	eleicaoTimeoutMin  = 150 * time.Millisecond
+	eleicaoTimeoutMax  = 300 * time.Millisecond
+	rpcClientTimeout   = 200 * time.Millisecond
+	propostaTimeoutMax = 5 * time.Second
+)
+
+// LogEntry é uma entrada do log replicado.
+type LogEntry struct {
+	Term     int    `json:"term"`
+	Index    int    `json:"index"`
+	Conteudo string `json:"conteudo"`
+}
+
+type estadoPersistente struct {
+	CurrentTerm int        `json:"current_term"`
+	VotedFor    int        `json:"voted_for"`
+	Log         []LogEntry `json:"log"`
+}
+
+// --- RPCs ---
+
+type AppendEntriesArgs struct {
+	Term         int        `json:"term"`
+	LeaderID     int        `json:"leader_id"`
+	PrevLogIndex int        `json:"prev_log_index"`
+	PrevLogTerm  int        `json:"prev_log_term"`
+	Entries      []LogEntry `json:"entries"`
+	LeaderCommit int        `json:"leader_commit"`
+}
+
+type AppendEntriesReply struct {
+	Term          int  `json:"term"`
+	Success       bool `json:"success"`
+	ConflictIndex int  `json:"conflict_index"`
+}
+
+type RequestVoteArgs struct {
+	Term         int `json:"term"`
+	CandidateID  int `json:"candidate_id"`
+	LastLogIndex int `json:"last_log_index"`
+	LastLogTerm  int `json:"last_log_term"`
+}
+
+type RequestVoteReply struct {
+	Term        int  `json:"term"`
+	VoteGranted bool `json:"vote_granted"`
+}
+
+type InstallSnapshotArgs struct {
+	Term              int    `json:"term"`
+	LeaderID          int    `json:"leader_id"`
+	LastIncludedIndex int    `json:"last_included_index"`
+	LastIncludedTerm  int    `json:"last_included_term"`
+	Data              []byte `json:"data"`
+}
+
+type InstallSnapshotReply struct {
+	Term int `json:"term"`
+}
+
+// Node é uma réplica Raft. Os campos de estado só podem ser acessados com
+// mu travado.
+type Node struct {
+	mu sync.Mutex
+
+	id    int
+	peers []string // URLs dos OUTROS processos, ex: http://proc2:8080
+
+	estado      string // "follower", "candidate" ou "leader"
+	currentTerm int
+	votedFor    int
+	log         []LogEntry
+
+	commitIndex int
+	lastApplied int
+	nextIndex   map[string]int
+	matchIndex  map[string]int
+
+	ultimoContatoLider time.Time
+	arquivoEstado      string
+	client             *http.Client
+	aplicar            func(LogEntry)
+
+	// esperandoCommit sinaliza quando o índice chave termina de ser aplicado,
+	// para que Propose() possa retornar assim que a entrada for comitada.
+	esperandoCommit map[int]chan struct{}
+
+	// applyMu serializa aplicarComitadas: avancarCommitIndex e
+	// HandleAppendEntries disparam "go n.aplicarComitadas()" de forma
+	// independente, e sem essa trava duas rodadas de commit concorrentes
+	// poderiam entrelaçar prints/aplicações fora da ordem do log.
+	applyMu sync.Mutex
+}
+
+// NovoNode cria uma réplica Raft. peers deve conter as URLs dos DEMAIS
+// processos (sem incluir a si mesmo). aplicar é chamado, em ordem de commit,
+// para cada entrada aplicada à máquina de estados.
+func NovoNode(id int, peers []string, aplicar func(LogEntry)) *Node {
+	n := &Node{
+		id:              id,
+		peers:           peers,
+		estado:          "follower",
+		votedFor:        0,
+		nextIndex:       make(map[string]int),
+		matchIndex:      make(map[string]int),
+		arquivoEstado:   fmt.Sprintf("raft_state_%d.json", id),
+		client:          &http.Client{Timeout: rpcClientTimeout},
+		aplicar:         aplicar,
+		esperandoCommit: make(map[int]chan struct{}),
+	}
+	n.carregarEstado()
+	return n
+}
+
+func (n *Node) carregarEstado() {
+	data, err := os.ReadFile(n.arquivoEstado)
+	if err != nil {
+		return
+	}
+	var st estadoPersistente
+	if err := json.Unmarshal(data, &st); err != nil {
+		fmt.Printf("[RAFT] erro ao carregar estado persistido: %v\n", err)
+		return
+	}
+	n.currentTerm = st.CurrentTerm
+	n.votedFor = st.VotedFor
+	n.log = st.Log
+}
+
+// salvarEstado grava term/votedFor/log em disco. Deve ser chamado com mu
+// travado, antes de responder a qualquer RPC que os altere.
+func (n *Node) salvarEstado() {
+	st := estadoPersistente{CurrentTerm: n.currentTerm, VotedFor: n.votedFor, Log: n.log}
+	data, err := json.Marshal(st)
+	if err != nil {
+		fmt.Printf("[RAFT] erro ao serializar estado: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(n.arquivoEstado, data, 0o644); err != nil {
+		fmt.Printf("[RAFT] erro ao persistir estado em %s: %v\n", n.arquivoEstado, err)
+	}
+}
+
+// Iniciar dispara os loops de fundo (timer de eleição e aplicação de log).
+func (n *Node) Iniciar() {
+	n.mu.Lock()
+	n.ultimoContatoLider = time.Now()
+	n.mu.Unlock()
+	go n.loopEleicao()
+}
+
+func timeoutAleatorio() time.Duration {
+	faixa := eleicaoTimeoutMax - eleicaoTimeoutMin
+	return eleicaoTimeoutMin + time.Duration(rand.Int63n(int64(faixa)))
+}
+
+func (n *Node) loopEleicao() {
+	for {
+		timeout := timeoutAleatorio()
+		time.Sleep(timeout)
+
+		n.mu.Lock()
+		souLider := n.estado == "leader"
+		silencio := time.Since(n.ultimoContatoLider)
+		n.mu.Unlock()
+
+		if souLider {
+			continue
+		}
+		if silencio >= timeout {
+			n.iniciarEleicao()
+		}
+	}
+}
+
+func (n *Node) ultimoLogIndiceTermo() (int, int) {
+	if len(n.log) == 0 {
+		return 0, 0
+	}
+	ultimo := n.log[len(n.log)-1]
+	return ultimo.Index, ultimo.Term
+}
+
+func (n *Node) iniciarEleicao() {
+	n.mu.Lock()
+	n.estado = "candidate"
+	n.currentTerm++
+	n.votedFor = n.id
+	n.salvarEstado()
+	termo := n.currentTerm
+	ultimoIndex, ultimoTerm := n.ultimoLogIndiceTermo()
+	n.ultimoContatoLider = time.Now()
+	peersCopia := append([]string(nil), n.peers...)
+	n.mu.Unlock()
+
+	fmt.Printf("[RAFT] Processo %d iniciou eleição para o termo %d\n", n.id, termo)
+
+	votos := 1 // voto em si mesmo
+	var votosMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, peer := range peersCopia {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			reply, err := n.enviarRequestVote(url, RequestVoteArgs{
+				Term:         termo,
+				CandidateID:  n.id,
+				LastLogIndex: ultimoIndex,
+				LastLogTerm:  ultimoTerm,
+			})
+			if err != nil {
+				return
+			}
+			n.mu.Lock()
+			if reply.Term > n.currentTerm {
+				n.tornarSeguidor(reply.Term)
+				n.mu.Unlock()
+				return
+			}
+			n.mu.Unlock()
+
+			if reply.VoteGranted {
+				votosMu.Lock()
+				votos++
+				votosMu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+
+	maioria := (len(peersCopia)+1)/2 + 1
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.estado == "candidate" && n.currentTerm == termo && votos >= maioria {
+		n.tornarSeLider()
+	}
+}
+
+// tornarSeLider deve ser chamado com mu travado.
+func (n *Node) tornarSeLider() {
+	n.estado = "leader"
+	proximoIndex, _ := n.ultimoLogIndiceTermo()
+	for _, peer := range n.peers {
+		n.nextIndex[peer] = proximoIndex + 1
+		n.matchIndex[peer] = 0
+	}
+	fmt.Printf("[RAFT] Processo %d é o novo LÍDER no termo %d\n", n.id, n.currentTerm)
+	go n.loopLider(n.currentTerm)
+}
+
+// tornarSeSeguidor deve ser chamado com mu travado.
+func (n *Node) tornarSeguidor(termo int) {
+	if termo > n.currentTerm {
+		n.currentTerm = termo
+		n.votedFor = 0
+		n.salvarEstado()
+	}
+	n.estado = "follower"
+	n.ultimoContatoLider = time.Now()
+}
+
+func (n *Node) loopLider(termoDaLideranca int) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.mu.Lock()
+		aindaLider := n.estado == "leader" && n.currentTerm == termoDaLideranca
+		peersCopia := append([]string(nil), n.peers...)
+		n.mu.Unlock()
+
+		if !aindaLider {
+			return
+		}
+		for _, peer := range peersCopia {
+			go n.replicarPara(peer, termoDaLideranca)
+		}
+	}
+}
+
+// replicarPara envia AppendEntries (heartbeat ou com entradas pendentes)
+// para um peer e avança nextIndex/matchIndex/commitIndex conforme a
+// resposta, truncando o log do peer em caso de conflito.
+func (n *Node) replicarPara(peer string, termoDaLideranca int) {
+	n.mu.Lock()
+	if n.estado != "leader" || n.currentTerm != termoDaLideranca {
+		n.mu.Unlock()
+		return
+	}
+	proximo := n.nextIndex[peer]
+	if proximo < 1 {
+		proximo = 1
+	}
+	prevLogIndex := proximo - 1
+	prevLogTerm := 0
+	if prevLogIndex > 0 && prevLogIndex <= len(n.log) {
+		prevLogTerm = n.log[prevLogIndex-1].Term
+	}
+	var entries []LogEntry
+	if proximo <= len(n.log) {
+		entries = append(entries, n.log[proximo-1:]...)
+	}
+	args := AppendEntriesArgs{
+		Term:         n.currentTerm,
+		LeaderID:     n.id,
+		PrevLogIndex: prevLogIndex,
+		PrevLogTerm:  prevLogTerm,
+		Entries:      entries,
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	reply, err := n.enviarAppendEntries(peer, args)
+	if err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if reply.Term > n.currentTerm {
+		n.tornarSeguidor(reply.Term)
+		return
+	}
+	if n.estado != "leader" || n.currentTerm != termoDaLideranca {
+		return
+	}
+	if reply.Success {
+		n.matchIndex[peer] = prevLogIndex + len(entries)
+		n.nextIndex[peer] = n.matchIndex[peer] + 1
+		n.avancarCommitIndex()
+		return
+	}
+	// Conflito: recua e tenta novamente na próxima rodada de heartbeat.
+	if reply.ConflictIndex > 0 {
+		n.nextIndex[peer] = reply.ConflictIndex
+	} else if n.nextIndex[peer] > 1 {
+		n.nextIndex[peer]--
+	}
+}
+
+// avancarCommitIndex deve ser chamado com mu travado. Avança commitIndex
+// para o maior N tal que uma maioria tenha matchIndex >= N e o log em N
+// pertença ao termo atual (regra de segurança do Raft).
+func (n *Node) avancarCommitIndex() {
+	for indice := len(n.log); indice > n.commitIndex; indice-- {
+		if n.log[indice-1].Term != n.currentTerm {
+			continue
+		}
+		replicado := 1 // o próprio líder já tem a entrada
+		for _, peer := range n.peers {
+			if n.matchIndex[peer] >= indice {
+				replicado++
+			}
+		}
+		if replicado >= (len(n.peers)+1)/2+1 {
+			n.commitIndex = indice
+			go n.aplicarComitadas()
+			break
+		}
+	}
+}
+
+func (n *Node) aplicarComitadas() {
+	n.applyMu.Lock()
+	defer n.applyMu.Unlock()
+
+	n.mu.Lock()
+	var pendentes []LogEntry
+	for n.lastApplied < n.commitIndex {
+		n.lastApplied++
+		pendentes = append(pendentes, n.log[n.lastApplied-1])
+	}
+	notificar := make([]chan struct{}, 0, len(pendentes))
+	for _, entry := range pendentes {
+		if ch, ok := n.esperandoCommit[entry.Index]; ok {
+			notificar = append(notificar, ch)
+			delete(n.esperandoCommit, entry.Index)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, entry := range pendentes {
+		fmt.Printf("[RAFT COMMIT] índice %d, termo %d, conteúdo: %s\n", entry.Index, entry.Term, entry.Conteudo)
+		if n.aplicar != nil {
+			n.aplicar(entry)
+		}
+	}
+	for _, ch := range notificar {
+		close(ch)
+	}
+}
+
+// Propose adiciona conteudo ao log (só o líder aceita) e bloqueia até a
+// entrada ser comitada por maioria ou até o timeout expirar.
+func (n *Node) Propose(conteudo string) (LogEntry, error) {
+	n.mu.Lock()
+	if n.estado != "leader" {
+		n.mu.Unlock()
+		return LogEntry{}, fmt.Errorf("processo %d não é o líder raft atual", n.id)
+	}
+	entry := LogEntry{Term: n.currentTerm, Index: len(n.log) + 1, Conteudo: conteudo}
+	n.log = append(n.log, entry)
+	n.salvarEstado()
+	ch := make(chan struct{})
+	n.esperandoCommit[entry.Index] = ch
+	n.mu.Unlock()
+
+	select {
+	case <-ch:
+		return entry, nil
+	case <-time.After(propostaTimeoutMax):
+		return entry, fmt.Errorf("timeout esperando commit do índice %d", entry.Index)
+	}
+}
+
+// --- Handlers de RPC (chamados pelos handlers Gin do pacote main) ---
+
+func (n *Node) HandleRequestVote(args RequestVoteArgs) RequestVoteReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if args.Term > n.currentTerm {
+		n.tornarSeguidor(args.Term)
+	}
+	if args.Term < n.currentTerm {
+		return RequestVoteReply{Term: n.currentTerm, VoteGranted: false}
+	}
+
+	ultimoIndex, ultimoTerm := n.ultimoLogIndiceTermo()
+	logAtualizado := args.LastLogTerm > ultimoTerm ||
+		(args.LastLogTerm == ultimoTerm && args.LastLogIndex >= ultimoIndex)
+
+	if (n.votedFor == 0 || n.votedFor == args.CandidateID) && logAtualizado {
+		n.votedFor = args.CandidateID
+		n.salvarEstado()
+		n.ultimoContatoLider = time.Now()
+		return RequestVoteReply{Term: n.currentTerm, VoteGranted: true}
+	}
+	return RequestVoteReply{Term: n.currentTerm, VoteGranted: false}
+}
+
+func (n *Node) HandleAppendEntries(args AppendEntriesArgs) AppendEntriesReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if args.Term < n.currentTerm {
+		return AppendEntriesReply{Term: n.currentTerm, Success: false}
+	}
+	n.tornarSeguidor(args.Term)
+
+	if args.PrevLogIndex > 0 {
+		if args.PrevLogIndex > len(n.log) {
+			return AppendEntriesReply{Term: n.currentTerm, Success: false, ConflictIndex: len(n.log) + 1}
+		}
+		if n.log[args.PrevLogIndex-1].Term != args.PrevLogTerm {
+			conflito := args.PrevLogIndex
+			termoConflito := n.log[args.PrevLogIndex-1].Term
+			for conflito > 1 && n.log[conflito-2].Term == termoConflito {
+				conflito--
+			}
+			n.log = n.log[:args.PrevLogIndex-1]
+			n.salvarEstado()
+			return AppendEntriesReply{Term: n.currentTerm, Success: false, ConflictIndex: conflito}
+		}
+	}
+
+	for i, entry := range args.Entries {
+		posicao := args.PrevLogIndex + i
+		if posicao < len(n.log) {
+			if n.log[posicao].Term != entry.Term {
+				n.log = append(n.log[:posicao], entry)
+			}
+		} else {
+			n.log = append(n.log, entry)
+		}
+	}
+	if len(args.Entries) > 0 {
+		n.salvarEstado()
+	}
+
+	if args.LeaderCommit > n.commitIndex {
+		novoCommit := args.LeaderCommit
+		if len(n.log) < novoCommit {
+			novoCommit = len(n.log)
+		}
+		n.commitIndex = novoCommit
+		go n.aplicarComitadas()
+	}
+
+	return AppendEntriesReply{Term: n.currentTerm, Success: true}
+}
+
+func (n *Node) HandleInstallSnapshot(args InstallSnapshotArgs) InstallSnapshotReply {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if args.Term < n.currentTerm {
+		return InstallSnapshotReply{Term: n.currentTerm}
+	}
+	n.tornarSeguidor(args.Term)
+
+	// Instalação de snapshot ainda não é suportada: todo o código de log
+	// (HandleAppendEntries, avancarCommitIndex, aplicarComitadas) indexa
+	// n.log por índice absoluto, sem nenhum offset de snapshot. Truncar
+	// n.log aqui sem esse bookkeeping desincronizaria a indexação de
+	// qualquer AppendEntries recebido depois. Como nenhum líder desta
+	// versão chega a enviar InstallSnapshot, o RPC fica registrado para
+	// compatibilidade futura do protocolo, mas não aplica nada ainda.
+	fmt.Printf("[RAFT] InstallSnapshot recebido (índice %d, termo %d) mas snapshots ainda não são suportados; ignorando.\n", args.LastIncludedIndex, args.LastIncludedTerm)
+
+	return InstallSnapshotReply{Term: n.currentTerm}
+}
+
+// --- Chamadas RPC de saída (cliente HTTP) ---
+
+func (n *Node) enviarRequestVote(peerURL string, args RequestVoteArgs) (RequestVoteReply, error) {
+	var reply RequestVoteReply
+	err := n.postJSON(peerURL+"/raft/vote", args, &reply)
+	return reply, err
+}
+
+func (n *Node) enviarAppendEntries(peerURL string, args AppendEntriesArgs) (AppendEntriesReply, error) {
+	var reply AppendEntriesReply
+	err := n.postJSON(peerURL+"/raft/append", args, &reply)
+	return reply, err
+}
+
+func (n *Node) postJSON(url string, corpo interface{}, resposta interface{}) error {
+	dados, err := json.Marshal(corpo)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar JSON: %w", err)
+	}
+	resp, err := n.client.Post(url, "application/json", bytes.NewBuffer(dados))
+	if err != nil {
+		return fmt.Errorf("erro ao enviar POST para %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("erro: %s respondeu com status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(resposta)
+}
+
+// Lider retorna se este processo acredita ser o líder atual.
+func (n *Node) Lider() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.estado == "leader"
+}
@@ -0,0 +1,113 @@
+package raft
+
+import (
+	"os"
+	"testing"
+)
+
+// novoNodeTeste cria um Node isolado (sem peers, sem goroutines de
+// eleição/heartbeat) e remove o raft_state_<id>.json que NovoNode grava ao
+// término do teste.
+func novoNodeTeste(t *testing.T, id int) *Node {
+	t.Helper()
+	n := NovoNode(id, nil, func(LogEntry) {})
+	t.Cleanup(func() { os.Remove(n.arquivoEstado) })
+	return n
+}
+
+func TestHandleAppendEntries(t *testing.T) {
+	casos := []struct {
+		nome         string
+		logInicial   []LogEntry
+		termoAtual   int
+		args         AppendEntriesArgs
+		querSucesso  bool
+		querConflito int
+		querLogFinal []LogEntry
+	}{
+		{
+			nome:        "termo do lider desatualizado é rejeitado",
+			termoAtual:  5,
+			args:        AppendEntriesArgs{Term: 4, LeaderID: 2},
+			querSucesso: false,
+		},
+		{
+			nome:         "prevLogIndex além do fim do log retorna conflictIndex = len(log)+1",
+			logInicial:   []LogEntry{{Term: 1, Index: 1, Conteudo: "a"}},
+			termoAtual:   1,
+			args:         AppendEntriesArgs{Term: 1, LeaderID: 2, PrevLogIndex: 3, PrevLogTerm: 1},
+			querSucesso:  false,
+			querConflito: 2,
+			querLogFinal: []LogEntry{{Term: 1, Index: 1, Conteudo: "a"}},
+		},
+		{
+			nome: "termo divergente em prevLogIndex trunca o log e recua até o início do termo em conflito",
+			logInicial: []LogEntry{
+				{Term: 1, Index: 1, Conteudo: "a"},
+				{Term: 2, Index: 2, Conteudo: "b"},
+				{Term: 2, Index: 3, Conteudo: "c"},
+			},
+			termoAtual:   2,
+			args:         AppendEntriesArgs{Term: 2, LeaderID: 2, PrevLogIndex: 3, PrevLogTerm: 3},
+			querSucesso:  false,
+			querConflito: 2,
+			querLogFinal: []LogEntry{
+				{Term: 1, Index: 1, Conteudo: "a"},
+				{Term: 2, Index: 2, Conteudo: "b"},
+			},
+		},
+		{
+			nome:       "entradas novas são anexadas quando prevLog bate",
+			logInicial: []LogEntry{{Term: 1, Index: 1, Conteudo: "a"}},
+			termoAtual: 1,
+			args: AppendEntriesArgs{
+				Term: 1, LeaderID: 2, PrevLogIndex: 1, PrevLogTerm: 1,
+				Entries: []LogEntry{{Term: 1, Index: 2, Conteudo: "b"}},
+			},
+			querSucesso: true,
+			querLogFinal: []LogEntry{
+				{Term: 1, Index: 1, Conteudo: "a"},
+				{Term: 1, Index: 2, Conteudo: "b"},
+			},
+		},
+		{
+			nome:       "entrada conflitante na mesma posição substitui o restante do log",
+			logInicial: []LogEntry{{Term: 1, Index: 1, Conteudo: "a"}, {Term: 1, Index: 2, Conteudo: "velha"}},
+			termoAtual: 2,
+			args: AppendEntriesArgs{
+				Term: 2, LeaderID: 2, PrevLogIndex: 1, PrevLogTerm: 1,
+				Entries: []LogEntry{{Term: 2, Index: 2, Conteudo: "nova"}},
+			},
+			querSucesso: true,
+			querLogFinal: []LogEntry{
+				{Term: 1, Index: 1, Conteudo: "a"},
+				{Term: 2, Index: 2, Conteudo: "nova"},
+			},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			n := novoNodeTeste(t, 1)
+			n.currentTerm = c.termoAtual
+			n.log = append([]LogEntry(nil), c.logInicial...)
+
+			reply := n.HandleAppendEntries(c.args)
+
+			if reply.Success != c.querSucesso {
+				t.Fatalf("Success = %v, quer %v (reply=%+v)", reply.Success, c.querSucesso, reply)
+			}
+			if !c.querSucesso && reply.ConflictIndex != c.querConflito {
+				t.Fatalf("ConflictIndex = %d, quer %d", reply.ConflictIndex, c.querConflito)
+			}
+			if len(n.log) != len(c.querLogFinal) {
+				t.Fatalf("log final tem %d entradas, quer %d (log=%+v)", len(n.log), len(c.querLogFinal), n.log)
+			}
+			for i, entry := range c.querLogFinal {
+				if n.log[i] != entry {
+					t.Fatalf("log[%d] = %+v, quer %+v", i, n.log[i], entry)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"container/heap"
+	"testing"
+)
+
+func TestFilaPrioritariaOrdenaPorTimestampEProcessID(t *testing.T) {
+	fila := &FilaPrioritaria{}
+	heap.Init(fila)
+
+	entradas := []Mensagem{
+		{Timestamp: 3, ProcessID: 1},
+		{Timestamp: 1, ProcessID: 2},
+		{Timestamp: 1, ProcessID: 1}, // mesmo timestamp da anterior, ProcessID menor desempata
+		{Timestamp: 2, ProcessID: 1},
+	}
+	for _, m := range entradas {
+		heap.Push(fila, m)
+	}
+
+	querOrdem := []Mensagem{
+		{Timestamp: 1, ProcessID: 1},
+		{Timestamp: 1, ProcessID: 2},
+		{Timestamp: 2, ProcessID: 1},
+		{Timestamp: 3, ProcessID: 1},
+	}
+
+	for i, esperado := range querOrdem {
+		got := heap.Pop(fila).(Mensagem)
+		if got.Timestamp != esperado.Timestamp || got.ProcessID != esperado.ProcessID {
+			t.Fatalf("pop %d = (ts=%d, pid=%d), quer (ts=%d, pid=%d)", i, got.Timestamp, got.ProcessID, esperado.Timestamp, esperado.ProcessID)
+		}
+	}
+	if fila.Len() != 0 {
+		t.Fatalf("fila deveria estar vazia, tem %d itens", fila.Len())
+	}
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"container/heap"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/MauricioMatheus/Sistemas-Distribuidos-AV2_1/consensus/raft"
 )
 
+// ErrFilaCheia é devolvido quando a fila de entrega (Q1) está no limite de
+// QUEUE_CAP; o transporte HTTP traduz isso para 429 para que o remetente
+// reduza a taxa de envio.
+var ErrFilaCheia = errors.New("fila de mensagens cheia")
+
 // --- ESTRUTURAS ---
 type Mensagem struct {
 	Conteudo  string `json:"conteudo"`
@@ -22,6 +31,39 @@ type Mensagem struct {
 	Tipo      string `json:"tipo"`
 	MaiorID   int    `json:"maior_id"`  // maior ID encontrado
 	OrigemID  int    `json:"origem_id"` // quem iniciou a eleição
+
+	// Época do token (Q2). Incrementada a cada regeneração, permite que
+	// receiveToken descarte tokens "fantasma" de processos ressuscitados.
+	TokenEpoch int `json:"token_epoch"`
+
+	// VectorClock (CLOCK_MODE=vector, causal.go) carrega o relógio vetorial
+	// do remetente no envio de uma CAUSAL_MSG, usado pelo destinatário para
+	// decidir quando a entrega respeita causalidade.
+	VectorClock []int `json:"vector_clock,omitempty"`
+}
+
+// FilaPrioritaria é um min-heap de Mensagem ordenado por (Timestamp,
+// ProcessID), usado para entregar em ordem total (Q1) sem reordenar a fila
+// inteira a cada chegada.
+type FilaPrioritaria []Mensagem
+
+func (f FilaPrioritaria) Len() int { return len(f) }
+func (f FilaPrioritaria) Less(i, j int) bool {
+	if f[i].Timestamp != f[j].Timestamp {
+		return f[i].Timestamp < f[j].Timestamp
+	}
+	return f[i].ProcessID < f[j].ProcessID
+}
+func (f FilaPrioritaria) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f *FilaPrioritaria) Push(x interface{}) {
+	*f = append(*f, x.(Mensagem))
+}
+func (f *FilaPrioritaria) Pop() interface{} {
+	antiga := *f
+	n := len(antiga)
+	item := antiga[n-1]
+	*f = antiga[:n-1]
+	return item
 }
 
 // --- VARIÁVEIS GLOBAIS ---
@@ -29,22 +71,175 @@ var (
 	meuID         int
 	relogioLogico int
 	mutex         sync.Mutex
-	fila          []Mensagem
-	// Tabela Hash para a contagem de acks. Chave: "TS_ID", Valor: Qtd Acks
-	acks         = make(map[string]int)
-	requiredAcks = 2
-	peers        = []string{"http://proc1:8080", "http://proc2:8080", "http://proc3:8080"}
+	fila          FilaPrioritaria
+	// acks conta quantos ACKs cada mensagem (chave "TS_ID") já recebeu. Os
+	// valores são *int64: sync.Map evita o mutex principal (que protege só
+	// fila/relogioLogico) para o acesso concorrente por receiveMessage/ACK,
+	// mas o próprio incremento ainda precisa ser atômico, já que dois ACKs
+	// para a mesma chave podem chegar ao mesmo tempo.
+	acks sync.Map
+	// requiredAcks é o número de ACKs exigido para liberar uma mensagem da
+	// fila (tentarProcessarMensagens): todos os demais processos, já que a
+	// ordenação total da Q1 depende de confirmação de todo mundo, não de
+	// maioria. Antes fixo em 2 para os 3 processos do docker-compose
+	// original; agora acompanha numProcessos (derivado de PEERS).
+	requiredAcks = numProcessos - 1
+	// filaCap limita quantas mensagens não entregues a fila Q1 mantém; acima
+	// disso, receiveMessage responde 429 para o remetente reduzir a taxa.
+	filaCap = filaCapDoAmbiente()
+	// peers lista "host:port" de cada processo, na ordem de ProcessID (peers[0]
+	// é o processo 1, e assim por diante). Descoberta via PEERS no lugar do
+	// slice fixo proc1..proc3 que existia antes.
+	peers = peersDoAmbiente()
+	// peersGRPC lista, na mesma ordem de ProcessID, o "host:port" em que
+	// cada processo escuta o servidor gRPC (GRPC_ADDR remoto), usado como
+	// destino de transporte.Send quando TRANSPORT=grpc no lugar de peers
+	// (que é sempre a porta HTTP/Gin, inclusive nesse modo, por causa do
+	// heartbeat em pingSucessor).
+	peersGRPC = peersGRPCDoAmbiente()
+
+	// transporte abstrai o envio/recebimento de Mensagem entre processos,
+	// permitindo trocar HTTP/JSON por gRPC via TRANSPORT=http|grpc.
+	transporte Transport
+	// usarGRPC é definido em main() a partir de TRANSPORT, e diz a
+	// enderecoTransporte se o destino de um Send deve vir de peersGRPC em
+	// vez de peers.
+	usarGRPC bool
 
 	// --- Q2: TOKEN RING ---
-	temToken         bool
-	processoSucessor int
-	emSecaoCritica   bool
+	temToken             bool
+	processoSucessor     int
+	emSecaoCritica       bool
+	tokenEpochAtual      int
+	ultimaAtividadeToken time.Time
+	// tokenSuspeito é marcado pelo watchdog (monitorarToken) ao disparar a
+	// eleição por ausência de atividade, e só é limpo quando o token volta a
+	// circular de fato (tokenHandler/passarToken) ou é regenerado. Não pode
+	// ser inferido de ultimaAtividadeToken, porque o próprio watchdog a
+	// reseta antes de iniciar a eleição (para não disparar de novo a cada
+	// tick enquanto ela corre).
+	tokenSuspeito bool
 
 	// --- Q3: ELEIÇÃO DE LÍDER ---
 	liderAtual   int
 	eleicaoAtiva bool
+
+	// electionAlgo seleciona entre o anel com COOR (padrão) e o Bully
+	// (bully.go) via ELECTION_ALGO=ring|bully.
+	electionAlgo string
+
+	// --- ORDER_MODE=raft: log replicado como alternativa ao Lamport T.O. ---
+	orderMode string
+	raftNode  *raft.Node
+
+	// --- CLOCK_MODE=vector: entrega causal como alternativa ao Lamport
+	// T.O. (ver causal.go) ---
+	clockMode string
 )
 
+const (
+	tokenTimeout           = 8 * time.Second
+	heartbeatInterval      = 2 * time.Second
+	heartbeatClientTimeout = 1 * time.Second
+)
+
+// numProcessos é derivado de peers (e portanto de PEERS), não mais fixo em 3.
+var numProcessos = len(peers)
+
+var httpClientHeartbeat = &http.Client{Timeout: heartbeatClientTimeout}
+
+// peersDoAmbiente lê PEERS (lista "host:port" separada por vírgula) e cai de
+// volta para os três processos do docker-compose padrão quando ausente.
+func peersDoAmbiente() []string {
+	valor := os.Getenv("PEERS")
+	if valor == "" {
+		return []string{"proc1:8080", "proc2:8080", "proc3:8080"}
+	}
+	var lista []string
+	for _, item := range strings.Split(valor, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			lista = append(lista, item)
+		}
+	}
+	return lista
+}
+
+// enderecoProcesso traduz um ProcessID (1-based) no "host:port" configurado
+// em PEERS para esse processo. Usado pelo heartbeat (pingSucessor), que é
+// sempre HTTP simples, independente de TRANSPORT.
+func enderecoProcesso(id int) string {
+	if id >= 1 && id <= len(peers) {
+		return peers[id-1]
+	}
+	return fmt.Sprintf("proc%d:8080", id)
+}
+
+// peersGRPCDoAmbiente lê PEERS_GRPC (lista "host:port" separada por vírgula,
+// na mesma ordem de PEERS). Quando ausente, deriva cada entrada do host de
+// PEERS na porta 9090, o padrão de GRPC_ADDR em NewGRPCTransport.
+func peersGRPCDoAmbiente() []string {
+	valor := os.Getenv("PEERS_GRPC")
+	if valor == "" {
+		lista := make([]string, len(peers))
+		for i, p := range peers {
+			host := p
+			if idx := strings.LastIndex(p, ":"); idx != -1 {
+				host = p[:idx]
+			}
+			lista[i] = host + ":9090"
+		}
+		return lista
+	}
+	var lista []string
+	for _, item := range strings.Split(valor, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			lista = append(lista, item)
+		}
+	}
+	return lista
+}
+
+// enderecoTransporte traduz um ProcessID no endereço usado por
+// transporte.Send: a porta gRPC (peersGRPC) quando TRANSPORT=grpc, ou a
+// mesma porta HTTP de enderecoProcesso caso contrário. Ao contrário do
+// heartbeat, o destino de um Send precisa necessariamente bater com o
+// protocolo ativo.
+func enderecoTransporte(id int) string {
+	if usarGRPC {
+		if id >= 1 && id <= len(peersGRPC) {
+			return peersGRPC[id-1]
+		}
+		return fmt.Sprintf("proc%d:9090", id)
+	}
+	return enderecoProcesso(id)
+}
+
+// filaCapDoAmbiente lê QUEUE_CAP, com 1000 como padrão.
+func filaCapDoAmbiente() int {
+	valor := os.Getenv("QUEUE_CAP")
+	if valor == "" {
+		return 1000
+	}
+	cap, err := strconv.Atoi(valor)
+	if err != nil || cap <= 0 {
+		return 1000
+	}
+	return cap
+}
+
+// acksPendentesAtual conta quantas entradas de ACK existem no momento, usado
+// para atualizar o gauge de métricas.
+func acksPendentesAtual() int {
+	total := 0
+	acks.Range(func(_, _ interface{}) bool {
+		total++
+		return true
+	})
+	return total
+}
+
 // --- LÓGICA DO RELÓGIO (Lamport) ---
 
 func atualizarRelogio(recebido int) {
@@ -54,41 +249,23 @@ func atualizarRelogio(recebido int) {
 		relogioLogico = recebido
 	}
 	relogioLogico++
+	relogioGauge.Set(float64(relogioLogico))
 }
 
 func incrementarRelogio() int {
 	mutex.Lock()
 	defer mutex.Unlock()
 	relogioLogico++
+	relogioGauge.Set(float64(relogioLogico))
 	return relogioLogico
 }
 
-// --- FUNÇÃO AUXILIAR DE REDE ---
-
-func sendRequest(url string, msg Mensagem) error {
-	msgJSON, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("erro ao serializar JSON: %w", err)
-	}
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(msgJSON))
-	if err != nil {
-		return fmt.Errorf("erro ao enviar POST para %s: %v", url, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("erro: %s respondeu com status %d", url, resp.StatusCode)
-	}
-	return nil
-}
-
 // --- Q1: ORDENAÇÃO TOTAL (Multicast + Lamport) ---
 
 func tentarProcessarMensagens() {
 	for {
 		mutex.Lock()
-		if len(fila) == 0 {
+		if fila.Len() == 0 {
 			mutex.Unlock()
 			return
 		}
@@ -96,10 +273,14 @@ func tentarProcessarMensagens() {
 		msg := fila[0]
 		key := fmt.Sprintf("%d_%d", msg.Timestamp, msg.ProcessID)
 
-		if acks[key] >= requiredAcks {
+		qtdAcks, _ := acks.Load(key)
+		if contador, ok := qtdAcks.(*int64); ok && atomic.LoadInt64(contador) >= int64(requiredAcks) {
 			fmt.Printf("\n[PROCESSADO] MSG T.O. -> TS: %d, ID: %d, Conteúdo: %s\n", msg.Timestamp, msg.ProcessID, msg.Conteudo)
-			fila = fila[1:]
-			delete(acks, key)
+			heap.Pop(&fila)
+			acks.Delete(key)
+			filaProfundidade.Set(float64(fila.Len()))
+			acksPendentesGauge.Set(float64(acksPendentesAtual()))
+			mensagensEntregues.Inc()
 			mutex.Unlock()
 			continue
 		}
@@ -108,40 +289,40 @@ func tentarProcessarMensagens() {
 	}
 }
 
-func receiveMessageInternal(msg Mensagem) {
+// receiveMessageInternal enfileira msg para entrega em ordem total. Retorna
+// ErrFilaCheia quando a fila atingiu QUEUE_CAP, sinalizando que o remetente
+// deve recuar (HTTP 429 na camada de transporte).
+func receiveMessageInternal(msg Mensagem) error {
 	atualizarRelogio(msg.Timestamp)
 
 	mutex.Lock()
+	if fila.Len() >= filaCap {
+		mutex.Unlock()
+		return ErrFilaCheia
+	}
 
-	fila = append(fila, msg)
-
-	sort.Slice(fila, func(i, j int) bool {
-		if fila[i].Timestamp != fila[j].Timestamp {
-			return fila[i].Timestamp < fila[j].Timestamp
-		}
-		return fila[i].ProcessID < fila[j].ProcessID
-	})
+	heap.Push(&fila, msg)
+	profundidade := fila.Len()
+	mutex.Unlock()
 
 	key := fmt.Sprintf("%d_%d", msg.Timestamp, msg.ProcessID)
-	acks[key] = 1
-
-	mutex.Unlock()
+	contadorInicial := int64(1)
+	acks.Store(key, &contadorInicial)
+	filaProfundidade.Set(float64(profundidade))
+	acksPendentesGauge.Set(float64(acksPendentesAtual()))
 
-	fmt.Printf("[RECEBIDO] MSG -> ID: %d, Conteúdo: %s. Fila agora: %d itens.\n", msg.ProcessID, msg.Conteudo, len(fila))
+	fmt.Printf("[RECEBIDO] MSG -> ID: %d, Conteúdo: %s. Fila agora: %d itens.\n", msg.ProcessID, msg.Conteudo, profundidade)
 
 	tentarProcessarMensagens()
+	return nil
 }
 
-// Handler para receber a mensagem de multicast de outro processo
-func receiveMessage(c *gin.Context) {
-	var msg Mensagem
-	if err := c.BindJSON(&msg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// Handler (via Transport) para receber a mensagem de multicast de outro processo
+func mensagemHandler(msg Mensagem) (Mensagem, error) {
+	if err := receiveMessageInternal(msg); err != nil {
+		return Mensagem{}, err
 	}
 
-	receiveMessageInternal(msg)
-
 	// Envia ACK de volta ao remetente
 	ackMsg := Mensagem{
 		Conteudo:  "ACK",
@@ -152,26 +333,20 @@ func receiveMessage(c *gin.Context) {
 		MaiorID: msg.Timestamp,
 	}
 
-	remetenteURL := fmt.Sprintf("http://proc%d:8080/ack", msg.ProcessID)
+	destino := enderecoTransporte(msg.ProcessID)
 	go func() {
-		if err := sendRequest(remetenteURL, ackMsg); err != nil {
-			fmt.Printf("ERRO: Falha ao enviar ACK para %s: %v\n", remetenteURL, err)
+		if err := transporte.Send(context.Background(), destino, ackMsg); err != nil {
+			fmt.Printf("ERRO: Falha ao enviar ACK para %s: %v\n", destino, err)
 		} else {
 			fmt.Printf("-> ACK enviado para Processo %d. TS: %d\n", msg.ProcessID, ackMsg.Timestamp)
 		}
 	}()
 
-	c.JSON(http.StatusOK, gin.H{"status": "message_received"})
+	return Mensagem{Tipo: "MESSAGE_ACK"}, nil
 }
 
-// Handler para receber o ACK de um peer
-func receiveACK(c *gin.Context) {
-	var ackMsg Mensagem
-	if err := c.BindJSON(&ackMsg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
+// Handler (via Transport) para receber o ACK de um peer
+func ackHandler(ackMsg Mensagem) (Mensagem, error) {
 	atualizarRelogio(ackMsg.Timestamp)
 
 	// TS da mensagem ORIGINAL que este ACK confirma (armazenado em ackMsg.MaiorID)
@@ -180,20 +355,19 @@ func receiveACK(c *gin.Context) {
 	// A chave é a mensagem original
 	key := fmt.Sprintf("%d_%d", msgTSOriginal, ackMsg.ProcessID)
 
-	mutex.Lock()
-	if _, ok := acks[key]; ok {
-		acks[key]++
-		fmt.Printf("[ACK RECEBIDO] Msg %s. Total: %d\n", key, acks[key])
+	if contador, ok := acks.Load(key); ok {
+		total := atomic.AddInt64(contador.(*int64), 1)
+		fmt.Printf("[ACK RECEBIDO] Msg %s. Total: %d\n", key, total)
 	}
-	mutex.Unlock()
 
 	tentarProcessarMensagens()
 
-	c.JSON(http.StatusOK, gin.H{"status": "ack_received"})
+	return Mensagem{Tipo: "ACK_ACK"}, nil
 }
 
-// Dispara o multicast para todos os peers (Q1)
-func dispararMulticast(conteudo string) Mensagem {
+// Dispara o multicast para todos os peers (Q1). Retorna ErrFilaCheia se a
+// própria fila local já estiver em QUEUE_CAP, sem enviar nada aos peers.
+func dispararMulticast(conteudo string) (Mensagem, error) {
 	msg := Mensagem{
 		Conteudo:  conteudo,
 		Timestamp: incrementarRelogio(),
@@ -201,44 +375,225 @@ func dispararMulticast(conteudo string) Mensagem {
 		Tipo:      "MESSAGE",
 	}
 
-	receiveMessageInternal(msg)
+	if err := receiveMessageInternal(msg); err != nil {
+		return Mensagem{}, err
+	}
+
+	for id := 1; id <= numProcessos; id++ {
+		if id == meuID {
+			continue
+		}
+		go func(destino int) {
+			addr := enderecoTransporte(destino)
+			fmt.Printf("-> Enviando Multicast para %s: Conteúdo: '%s' TS: %d\n", addr, msg.Conteudo, msg.Timestamp)
+			if err := transporte.Send(context.Background(), addr, msg); err != nil {
+				fmt.Printf("ERRO: Falha ao enviar multicast para %s: %v\n", addr, err)
+			}
+		}(id)
+	}
+	return msg, nil
+}
+
+// --- ORDER_MODE=raft: endpoints do log replicado (alternativa ao Q1) ---
 
-	for _, peerURL := range peers {
-		if peerURL != fmt.Sprintf("http://proc%d:8080", meuID) {
-			go func(url string) {
-				fmt.Printf("-> Enviando Multicast para %s: Conteúdo: '%s' TS: %d\n", url, msg.Conteudo, msg.Timestamp)
-				if err := sendRequest(url+"/receive", msg); err != nil {
-					fmt.Printf("ERRO: Falha ao enviar multicast para %s: %v\n", url, err)
-				}
-			}(peerURL)
+func raftPeerURLs() []string {
+	meuEndereco := enderecoProcesso(meuID)
+	var outros []string
+	for _, peerAddr := range peers {
+		if peerAddr != meuEndereco {
+			outros = append(outros, "http://"+peerAddr)
 		}
 	}
-	return msg
+	return outros
+}
+
+func raftAppend(c *gin.Context) {
+	if raftNode == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ORDER_MODE=raft não está ativo"})
+		return
+	}
+	var args raft.AppendEntriesArgs
+	if err := c.BindJSON(&args); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, raftNode.HandleAppendEntries(args))
+}
+
+func raftVote(c *gin.Context) {
+	if raftNode == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ORDER_MODE=raft não está ativo"})
+		return
+	}
+	var args raft.RequestVoteArgs
+	if err := c.BindJSON(&args); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, raftNode.HandleRequestVote(args))
+}
+
+func raftInstallSnapshot(c *gin.Context) {
+	if raftNode == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ORDER_MODE=raft não está ativo"})
+		return
+	}
+	var args raft.InstallSnapshotArgs
+	if err := c.BindJSON(&args); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, raftNode.HandleInstallSnapshot(args))
+}
+
+// Handler de cliente: só retorna depois que a entrada é comitada por maioria.
+func raftPropose(c *gin.Context) {
+	if raftNode == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ORDER_MODE=raft não está ativo"})
+		return
+	}
+	var req struct {
+		Conteudo string `json:"conteudo"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'conteudo' in request"})
+		return
+	}
+
+	entry, err := raftNode.Propose(req.Conteudo)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "committed", "entry": entry})
 }
 
 // --- Q2: EXCLUSÃO MÚTUA (TOKEN RING) ---
 
+// pingSucessor verifica, via /test, se o processo "id" está vivo.
+func pingSucessor(id int) bool {
+	url := fmt.Sprintf("http://%s/test", enderecoProcesso(id))
+	resp, err := httpClientHeartbeat.Get(url)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// proximoVivo calcula, a partir de meuID, o próximo processo do anel que
+// responde ao heartbeat, pulando sucessores suspeitos de falha. Se nenhum
+// outro processo responder, retorna o próprio meuID (anel de 1 membro).
+func proximoVivo() int {
+	candidato := (meuID % numProcessos) + 1
+	for tentativas := 0; tentativas < numProcessos; tentativas++ {
+		if candidato == meuID {
+			return meuID
+		}
+		if pingSucessor(candidato) {
+			return candidato
+		}
+		fmt.Printf("[FALHA SUSPEITA] Processo %d não respondeu ao heartbeat. Pulando.\n", candidato)
+		candidato = (candidato % numProcessos) + 1
+	}
+	return meuID
+}
+
 func passarToken() {
+	sucessor := proximoVivo()
+
+	mutex.Lock()
+	processoSucessor = sucessor
+	epoch := tokenEpochAtual
+	mutex.Unlock()
+
 	tokenMsg := Mensagem{
-		Conteudo:  "TOKEN",
-		Timestamp: incrementarRelogio(),
-		ProcessID: meuID,
-		Tipo:      "TOKEN",
+		Conteudo:   "TOKEN",
+		Timestamp:  incrementarRelogio(),
+		ProcessID:  meuID,
+		Tipo:       "TOKEN",
+		TokenEpoch: epoch,
+	}
+
+	if sucessor == meuID {
+		// Ninguém mais no anel respondeu: permanecemos com o token.
+		fmt.Println("[TOKEN] Nenhum sucessor vivo encontrado. Mantendo o token.")
+		return
 	}
 
-	sucessorURL := fmt.Sprintf("http://proc%d:8080/token", processoSucessor)
+	sucessorAddr := enderecoTransporte(sucessor)
 
 	go func() {
-		fmt.Printf("-> Passando TOKEN para Processo %d...\n", processoSucessor)
-		if err := sendRequest(sucessorURL, tokenMsg); err != nil {
-			fmt.Printf("ERRO: Falha ao passar TOKEN para %s: %v\n", sucessorURL, err)
-		} else {
+		fmt.Printf("-> Passando TOKEN (epoch %d) para Processo %d...\n", tokenMsg.TokenEpoch, sucessor)
+		if err := transporte.Send(context.Background(), sucessorAddr, tokenMsg); err != nil {
+			fmt.Printf("ERRO: Falha ao passar TOKEN para %s: %v\n", sucessorAddr, err)
+			return
+		}
+		mutex.Lock()
+		temToken = false
+		ultimaAtividadeToken = time.Now()
+		tokenSuspeito = false
+		mutex.Unlock()
+		fmt.Println("-> TOKEN entregue com sucesso.")
+	}()
+}
+
+// regenerarToken é chamado pelo processo eleito líder quando o token é
+// considerado perdido (token suspeito pelo watchdog, ver tokenSuspeito).
+// Incrementa a época para que cópias antigas do token, vindas de processos
+// ressuscitados, sejam rejeitadas por receiveToken.
+func regenerarToken() {
+	mutex.Lock()
+	tokenEpochAtual++
+	temToken = true
+	ultimaAtividadeToken = time.Now()
+	tokenSuspeito = false
+	epoch := tokenEpochAtual
+	mutex.Unlock()
+
+	fmt.Printf("[TOKEN REGENERADO] Líder %d mintou um novo token (epoch %d).\n", meuID, epoch)
+	processarSC()
+}
+
+// tentarRegenerarTokenSeLider regenera o token quando, e somente quando,
+// este processo é o líder recém-eleito E o watchdog (monitorarToken) marcou
+// o token como suspeito. É chamada tanto por quem conclui a eleição no anel
+// quanto por quem recebe o COOR como novo líder (coordinatorHandler) e pelo
+// Bully (declararLiderBully), para que a regeneração não dependa de quem
+// iniciou a rodada, só de quem venceu.
+func tentarRegenerarTokenSeLider() {
+	mutex.Lock()
+	souLider := liderAtual == meuID
+	suspeito := tokenSuspeito
+	mutex.Unlock()
+
+	if souLider && suspeito {
+		regenerarToken()
+	}
+}
+
+// monitorarToken é o watchdog de falha do anel: se o processo não vê
+// atividade do token (posse ou repasse) por tokenTimeout, suspeita que o
+// token se perdeu junto com algum peer e dispara uma eleição para que o
+// vencedor regenere o token.
+func monitorarToken() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		mutex.Lock()
+		silencio := time.Since(ultimaAtividadeToken)
+		possuiToken := temToken
+		mutex.Unlock()
+
+		if !possuiToken && silencio > tokenTimeout {
+			fmt.Printf("[WATCHDOG] Nenhuma atividade do token há %s. Iniciando eleição para regeneração.\n", silencio.Round(time.Second))
 			mutex.Lock()
-			temToken = false
+			ultimaAtividadeToken = time.Now()
+			tokenSuspeito = true
 			mutex.Unlock()
-			fmt.Println("-> TOKEN entregue com sucesso.")
+			iniciarEleicao()
 		}
-	}()
+	}
 }
 
 func processarSC() {
@@ -250,40 +605,49 @@ func processarSC() {
 	emSecaoCritica = true
 	mutex.Unlock()
 
+	inicioPosse := time.Now()
+
 	fmt.Println("\n=============================================")
 	fmt.Printf("ENTRANDO na Seção Crítica (SC) - TS: %d\n", incrementarRelogio())
 	time.Sleep(3 * time.Second)
 	fmt.Printf("SAINDO da Seção Crítica (SC) - TS: %d\n", incrementarRelogio())
-	fmt.Println("=============================================\n")
+	fmt.Println("=============================================")
 
 	mutex.Lock()
 	emSecaoCritica = false
 	mutex.Unlock()
 
+	tempoPosseToken.Observe(time.Since(inicioPosse).Seconds())
+
 	passarToken()
 }
 
-// Handler de Token (Q2)
-func receiveToken(c *gin.Context) {
-	var msg Mensagem
-	c.BindJSON(&msg)
+// Handler (via Transport) de Token (Q2)
+func tokenHandler(msg Mensagem) (Mensagem, error) {
 	atualizarRelogio(msg.Timestamp)
 
 	mutex.Lock()
+	if msg.TokenEpoch < tokenEpochAtual {
+		fmt.Printf("[WARNING] Token obsoleto (epoch %d < %d) de um processo ressuscitado. Descartando.\n", msg.TokenEpoch, tokenEpochAtual)
+		mutex.Unlock()
+		return Mensagem{Tipo: "TOKEN_OBSOLETO"}, nil
+	}
 	if temToken {
 		fmt.Println("[WARNING] Recebendo um token extra. Ignorando.")
 		mutex.Unlock()
-		c.JSON(http.StatusOK, gin.H{"status": "token_ignorado"})
-		return
+		return Mensagem{Tipo: "TOKEN_IGNORADO"}, nil
 	}
+	tokenEpochAtual = msg.TokenEpoch
 	temToken = true
+	ultimaAtividadeToken = time.Now()
+	tokenSuspeito = false
 	mutex.Unlock()
 
 	fmt.Printf("[TOKEN RECEBIDO] Processo %d agora possui o token.\n", meuID)
 
 	processarSC()
 
-	c.JSON(http.StatusOK, gin.H{"status": "token_ok"})
+	return Mensagem{Tipo: "TOKEN_OK"}, nil
 }
 
 // Handler de Requisição de SC (Q2)
@@ -312,12 +676,15 @@ func announceCoordinator(liderID int) {
 		Tipo:      "COOR",
 	}
 
-	sucessorURL := fmt.Sprintf("http://proc%d:8080/coordinator", processoSucessor)
+	// proximoVivo (não o processoSucessor estático) para não tentar anunciar
+	// a um sucessor que já caiu desde a última passagem do token.
+	sucessor := proximoVivo()
+	sucessorAddr := enderecoTransporte(sucessor)
 
 	go func() {
-		fmt.Printf("-> ANUNCIANDO NOVO LÍDER (%d) para Processo %d...\n", liderID, processoSucessor)
-		if err := sendRequest(sucessorURL, msgCoord); err != nil {
-			fmt.Printf("ERRO: Falha ao anunciar COOR para %s: %v\n", sucessorURL, err)
+		fmt.Printf("-> ANUNCIANDO NOVO LÍDER (%d) para Processo %d...\n", liderID, sucessor)
+		if err := transporte.Send(context.Background(), sucessorAddr, msgCoord); err != nil {
+			fmt.Printf("ERRO: Falha ao anunciar COOR para %s: %v\n", sucessorAddr, err)
 		}
 	}()
 }
@@ -339,18 +706,28 @@ func propagarEleicao(maiorIDAtual int) {
 		MaiorID:   maiorIDAtual,
 	}
 
-	sucessorURL := fmt.Sprintf("http://proc%d:8080/eleicao", processoSucessor)
+	sucessor := proximoVivo()
+	sucessorAddr := enderecoTransporte(sucessor)
 
 	go func() {
-		fmt.Printf("-> Propagando ELEIÇÃO (Líder Provisório: %d) para Processo %d...\n", maiorIDAtual, processoSucessor)
-		if err := sendRequest(sucessorURL, msgEleicao); err != nil {
-			fmt.Printf("ERRO: Falha ao propagar ELEIÇÃO para %s: %v\n", sucessorURL, err)
+		fmt.Printf("-> Propagando ELEIÇÃO (Líder Provisório: %d) para Processo %d...\n", maiorIDAtual, sucessor)
+		if err := transporte.Send(context.Background(), sucessorAddr, msgEleicao); err != nil {
+			fmt.Printf("ERRO: Falha ao propagar ELEIÇÃO para %s: %v\n", sucessorAddr, err)
+			mutex.Lock()
+			eleicaoAtiva = false
+			mutex.Unlock()
 		}
 	}()
 }
 
-// Função que inicia a eleição
+// Função que inicia a eleição. Delega ao Bully (bully.go) quando
+// ELECTION_ALGO=bully; caso contrário usa o anel com COOR abaixo.
 func iniciarEleicao() {
+	if electionAlgo == "bully" {
+		iniciarEleicaoBully()
+		return
+	}
+
 	mutex.Lock()
 	if eleicaoAtiva {
 		mutex.Unlock()
@@ -359,6 +736,7 @@ func iniciarEleicao() {
 	eleicaoAtiva = true
 	mutex.Unlock()
 
+	eleicoesIniciadas.Inc()
 	fmt.Printf("\n[ELEIÇÃO] Processo %d iniciou a eleição\n", meuID)
 
 	msg := Mensagem{
@@ -367,15 +745,19 @@ func iniciarEleicao() {
 		MaiorID:  meuID,
 	}
 
-	url := fmt.Sprintf("http://proc%d:8080/eleicao", processoSucessor)
-	go sendRequest(url, msg)
+	sucessor := proximoVivo()
+	go func() {
+		if err := transporte.Send(context.Background(), enderecoTransporte(sucessor), msg); err != nil {
+			fmt.Printf("ERRO: Falha ao iniciar ELEIÇÃO para %d: %v\n", sucessor, err)
+			mutex.Lock()
+			eleicaoAtiva = false
+			mutex.Unlock()
+		}
+	}()
 }
 
-// Handler de Eleição (Q3)
-func receiveEleicao(c *gin.Context) {
-	var msg Mensagem
-	c.BindJSON(&msg)
-
+// Handler (via Transport) de Eleição (Q3)
+func eleicaoHandler(msg Mensagem) (Mensagem, error) {
 	// Atualiza maior ID
 	if meuID > msg.MaiorID {
 		msg.MaiorID = meuID
@@ -388,45 +770,66 @@ func receiveEleicao(c *gin.Context) {
 		eleicaoAtiva = false
 		mutex.Unlock()
 
+		eleicoesConcluidas.Inc()
+		persistirLider(liderAtual)
 		fmt.Printf("\n[ELEIÇÃO FINALIZADA] Líder eleito: Processo %d\n\n", liderAtual)
 		announceCoordinator(liderAtual)
-		c.JSON(200, gin.H{"status": "eleicao_finalizada"})
-		return
+		tentarRegenerarTokenSeLider()
+
+		return Mensagem{Tipo: "ELEICAO_FINALIZADA"}, nil
 	}
 
 	// Propaga normalmente
 	msg.ProcessID = meuID
-	url := fmt.Sprintf("http://proc%d:8080/eleicao", processoSucessor)
-	go sendRequest(url, msg)
+	sucessor := proximoVivo()
+	go func() {
+		if err := transporte.Send(context.Background(), enderecoTransporte(sucessor), msg); err != nil {
+			fmt.Printf("ERRO: Falha ao propagar ELEIÇÃO para %d: %v\n", sucessor, err)
+			mutex.Lock()
+			eleicaoAtiva = false
+			mutex.Unlock()
+		}
+	}()
 
-	c.JSON(200, gin.H{"status": "eleicao_propagada"})
+	return Mensagem{Tipo: "ELEICAO_PROPAGADA"}, nil
 }
 
-// Handler COOR: Recebe o novo líder (Q3)
-func receiveCoordinator(c *gin.Context) {
-	var msg Mensagem
-	c.BindJSON(&msg)
+// Handler (via Transport) COOR: Recebe o novo líder (Q3)
+func coordinatorHandler(msg Mensagem) (Mensagem, error) {
+	// Destrava aguardarCoordenadorBully (bully.go) se este processo estiver
+	// esperando o COOR do superior que respondeu ALIVE; no-op caso contrário
+	// ou no modo anel.
+	notificarCoordenadorBully()
 
 	mutex.Lock()
 	if !eleicaoAtiva && liderAtual == msg.ProcessID {
 		mutex.Unlock()
-		c.JSON(200, gin.H{"status": "coor_ignorado"})
-		return
+		return Mensagem{Tipo: "COOR_IGNORADO"}, nil
 	}
 
 	eleicaoAtiva = false
 	liderAtual = msg.ProcessID
 	mutex.Unlock()
+	persistirLider(liderAtual)
 
 	fmt.Printf("[COORDENADOR] Novo líder confirmado: Processo %d\n", liderAtual)
-
-	// Propaga apenas uma vez
-	if meuID != msg.ProcessID {
-		url := fmt.Sprintf("http://proc%d:8080/coordinator", processoSucessor)
-		go sendRequest(url, msg)
+	// O vencedor da eleição pode não ser quem a iniciou (eleicaoHandler só
+	// regenera quando liderAtual==meuID na própria conclusão do loop), então
+	// o novo líder também tenta regenerar aqui, ao confirmar a si mesmo.
+	tentarRegenerarTokenSeLider()
+
+	// Propaga apenas uma vez, e só no modo anel: no Bully o próprio líder já
+	// avisa todos os processos de ID menor diretamente (declararLiderBully).
+	if meuID != msg.ProcessID && electionAlgo == "ring" {
+		sucessor := proximoVivo()
+		go func() {
+			if err := transporte.Send(context.Background(), enderecoTransporte(sucessor), msg); err != nil {
+				fmt.Printf("ERRO: Falha ao propagar COOR para %d: %v\n", sucessor, err)
+			}
+		}()
 	}
 
-	c.JSON(200, gin.H{"status": "coordinator_ok"})
+	return Mensagem{Tipo: "COORDINATOR_OK"}, nil
 }
 
 // --- HANDLERS DIVERSOS ---
@@ -436,7 +839,9 @@ func startInternalTest(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
-// Handler para o usuário disparar uma mensagem de multicast (Q1)
+// Handler para o usuário disparar uma mensagem de multicast (Q1).
+// Quando ORDER_MODE=raft, delega a ordenação ao log replicado em vez de
+// usar a contagem de ACKs do Lamport T.O.
 func startMulticast(c *gin.Context) {
 	var req struct {
 		Conteudo string `json:"conteudo"`
@@ -446,7 +851,21 @@ func startMulticast(c *gin.Context) {
 		return
 	}
 
-	msg := dispararMulticast(req.Conteudo)
+	if orderMode == "raft" {
+		entry, err := raftNode.Propose(req.Conteudo)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "multicast_started", "entry": entry})
+		return
+	}
+
+	msg, err := dispararMulticast(req.Conteudo)
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "multicast_started", "message": msg})
 }
 
@@ -473,32 +892,101 @@ func main() {
 	} else {
 		temToken = false
 	}
-	processoSucessor = (meuID % 3) + 1
+	processoSucessor = (meuID % numProcessos) + 1
 	emSecaoCritica = false
+	tokenEpochAtual = 0
+	ultimaAtividadeToken = time.Now()
+	go monitorarToken()
 
 	// --- Q3: INICIALIZAÇÃO DA ELEIÇÃO ---
 	liderAtual = 1
 	eleicaoAtiva = false
 
+	electionAlgo = os.Getenv("ELECTION_ALGO")
+	if electionAlgo == "" {
+		electionAlgo = "ring"
+	}
+	carregarLiderPersistido()
+	fmt.Printf("--- ELECTION_ALGO=%s ---\n", electionAlgo)
+
+	// --- ORDER_MODE: lamport (padrão) ou raft ---
+	orderMode = os.Getenv("ORDER_MODE")
+	if orderMode == "" {
+		orderMode = "lamport"
+	}
+	if orderMode == "raft" {
+		raftNode = raft.NovoNode(meuID, raftPeerURLs(), func(entry raft.LogEntry) {
+			fmt.Printf("[PROCESSADO] MSG RAFT -> Índice: %d, Termo: %d, Conteúdo: %s\n", entry.Index, entry.Term, entry.Conteudo)
+		})
+		raftNode.Iniciar()
+		fmt.Println("--- ORDER_MODE=raft: log replicado ativo ---")
+	}
+
+	// --- CLOCK_MODE: lamport (padrão, T.O. da Q1) ou vector (causal) ---
+	clockMode = os.Getenv("CLOCK_MODE")
+	if clockMode == "" {
+		clockMode = "lamport"
+	}
+	vetorLocal = make([]int, numProcessos)
+	if clockMode == "vector" {
+		fmt.Println("--- CLOCK_MODE=vector: entrega causal ativa ---")
+	}
+
 	gin.DisableBindValidation()
 	router := gin.New()
 	router.Use(gin.Logger())
 	router.Use(gin.RecoveryWithWriter(gin.DefaultErrorWriter))
+	router.Use(metricasMiddleware())
+	router.GET("/metrics", metricsHandler())
+
+	// --- TRANSPORT: http (padrão) ou grpc ---
+	switch os.Getenv("TRANSPORT") {
+	case "grpc":
+		usarGRPC = true
+		grpcAddr := os.Getenv("GRPC_ADDR")
+		if grpcAddr == "" {
+			grpcAddr = ":9090"
+		}
+		grpcTransport, err := NewGRPCTransport(grpcAddr)
+		if err != nil {
+			fmt.Printf("ERRO: não foi possível iniciar o transporte gRPC: %v\n", err)
+			os.Exit(1)
+		}
+		transporte = grpcTransport
+		fmt.Printf("--- TRANSPORT=grpc: peers gRPC %v ---\n", peersGRPC)
+	default:
+		transporte = NewHTTPTransport(router)
+	}
 
-	// Endpoints da Q1 (Ordenação Total)
-	router.POST("/receive", receiveMessage)
-	router.POST("/ack", receiveACK)
+	// Endpoints da Q1 (Ordenação Total), via Transport
+	transporte.Register(Handler{Path: "/receive", Tipo: "MESSAGE", Fn: mensagemHandler})
+	transporte.Register(Handler{Path: "/ack", Tipo: "ACK", Fn: ackHandler})
 	router.POST("/start", startMulticast)
 	router.GET("/test", startInternalTest)
 
-	// Endpoints da Q2 (Token Ring)
-	router.POST("/token", receiveToken)
+	// Endpoints da Q2 (Token Ring), via Transport
+	transporte.Register(Handler{Path: "/token", Tipo: "TOKEN", Fn: tokenHandler})
 	router.POST("/request_sc", requestSC)
 
-	// Endpoints da Q3 (Eleição de Líder)
-	router.POST("/eleicao", receiveEleicao)
+	// Endpoints da Q3 (Eleição de Líder): anel (padrão) e Bully, via Transport.
+	// Ambos ficam registrados independente de ELECTION_ALGO, que só decide
+	// qual iniciarEleicao() usa para disparar uma nova rodada.
+	transporte.Register(Handler{Path: "/eleicao", Tipo: "ELEICAO", Fn: eleicaoHandler})
+	transporte.Register(Handler{Path: "/coordinator", Tipo: "COOR", Fn: coordinatorHandler})
+	transporte.Register(Handler{Path: "/bully/election", Tipo: "BULLY_ELECTION", Fn: bullyElectionHandler})
+	transporte.Register(Handler{Path: "/bully/alive", Tipo: "BULLY_ALIVE", Fn: bullyAliveHandler})
 	router.POST("/start_eleicao", startEleicao)
-	router.POST("/coordinator", receiveCoordinator) // Nova Rota COOR
+
+	// Endpoints do log replicado (ORDER_MODE=raft)
+	router.POST("/raft/append", raftAppend)
+	router.POST("/raft/vote", raftVote)
+	router.POST("/raft/install_snapshot", raftInstallSnapshot)
+	router.POST("/raft/propose", raftPropose)
+
+	// Endpoints da entrega causal (CLOCK_MODE=vector), via Transport
+	transporte.Register(Handler{Path: "/causal_receive", Tipo: "CAUSAL_MSG", Fn: causalMessageHandler})
+	router.POST("/causal_send", causalSend)
+	router.GET("/clock", clockHandler)
 
 	fmt.Printf("=== Processo %d rodando | Sucessor: %d | Lider Inicial: %d ===\n", meuID, processoSucessor, liderAtual)
 	router.Run(":8080")
@@ -0,0 +1,91 @@
+package main
+
+// Métricas Prometheus expostas em /metrics, cobrindo a fila de entrega Q1, o
+// anel de token Q2 e a eleição Q3. Mantidas em um arquivo separado porque não
+// pertencem à lógica de nenhum subsistema específico, só observam os demais.
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// filaProfundidade acompanha quantas mensagens a fila Q1 mantém sem
+	// entrega confirmada, usado para dimensionar QUEUE_CAP.
+	filaProfundidade = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sdav2_fila_profundidade",
+		Help: "Número de mensagens na fila de ordenação total (Q1) aguardando entrega.",
+	})
+
+	// acksPendentesGauge acompanha quantas mensagens ainda não atingiram
+	// requiredAcks.
+	acksPendentesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sdav2_acks_pendentes",
+		Help: "Número de mensagens aguardando ACKs suficientes para entrega (Q1).",
+	})
+
+	// mensagensEntregues conta quantas mensagens já foram entregues em ordem
+	// total (Q1).
+	mensagensEntregues = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sdav2_mensagens_entregues_total",
+		Help: "Total de mensagens entregues em ordem total (Q1).",
+	})
+
+	// relogioGauge expõe o valor atual do relógio lógico de Lamport.
+	relogioGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sdav2_relogio_logico",
+		Help: "Valor atual do relógio lógico de Lamport deste processo.",
+	})
+
+	// tempoPosseToken mede, em segundos, quanto tempo o processo passa na
+	// seção crítica de posse do token (Q2).
+	tempoPosseToken = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sdav2_tempo_posse_token_segundos",
+		Help:    "Tempo em segundos que o processo permanece na seção crítica com o token (Q2).",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// eleicoesIniciadas/eleicoesConcluidas contam rodadas de eleição (Q3).
+	eleicoesIniciadas = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sdav2_eleicoes_iniciadas_total",
+		Help: "Total de eleições de líder iniciadas por este processo (Q3).",
+	})
+	eleicoesConcluidas = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sdav2_eleicoes_concluidas_total",
+		Help: "Total de eleições de líder concluídas vistas por este processo (Q3).",
+	})
+
+	// latenciaEndpoint mede a latência dos endpoints HTTP expostos pelo gin.
+	latenciaEndpoint = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sdav2_latencia_endpoint_segundos",
+		Help:    "Latência por rota HTTP, em segundos.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"rota", "metodo", "status"})
+)
+
+// metricasMiddleware registra a latência de cada requisição por rota no
+// histograma latenciaEndpoint.
+func metricasMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+		rota := c.FullPath()
+		if rota == "" {
+			rota = "desconhecida"
+		}
+		status := c.Writer.Status()
+		latenciaEndpoint.WithLabelValues(rota, c.Request.Method, fmt.Sprintf("%d", status)).Observe(time.Since(inicio).Seconds())
+	}
+}
+
+func metricsHandler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+// Abstração de transporte: desacopla o envio/recebimento de Mensagem do
+// protocolo de rede usado, permitindo trocar HTTP/JSON por gRPC via a
+// variável de ambiente TRANSPORT sem tocar na lógica de Q1/Q2/Q3.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tipoParaPath mapeia o campo Tipo da Mensagem para a rota HTTP
+// correspondente, permitindo que Send resolva o endpoint a partir do
+// conteúdo da mensagem em vez de receber a URL completa.
+var tipoParaPath = map[string]string{
+	"MESSAGE":        "/receive",
+	"ACK":            "/ack",
+	"TOKEN":          "/token",
+	"ELEICAO":        "/eleicao",
+	"COOR":           "/coordinator",
+	"BULLY_ELECTION": "/bully/election",
+	"BULLY_ALIVE":    "/bully/alive",
+	"CAUSAL_MSG":     "/causal_receive",
+}
+
+// Transport é implementado tanto pelo transporte HTTP/JSON original quanto
+// pelo novo transporte gRPC, permitindo que Q1/Q2/Q3 enviem e recebam
+// Mensagem sem conhecer o protocolo concreto.
+type Transport interface {
+	// Send entrega msg ao processo identificado por peer ("host:port").
+	Send(ctx context.Context, peer string, msg Mensagem) error
+	// Register associa um handler ao tipo/rota de mensagem indicados.
+	Register(h Handler)
+}
+
+// Handler associa uma rota HTTP e um tipo de Mensagem à função que
+// processa o recebimento, compartilhada entre os dois transportes.
+type Handler struct {
+	Path string
+	Tipo string
+	Fn   func(Mensagem) (Mensagem, error)
+}
+
+// --- Transporte HTTP/JSON (implementação original, agora por trás da interface) ---
+
+type HTTPTransport struct {
+	router *gin.Engine
+	client *http.Client
+}
+
+func NewHTTPTransport(router *gin.Engine) *HTTPTransport {
+	return &HTTPTransport{router: router, client: &http.Client{}}
+}
+
+func (t *HTTPTransport) Send(ctx context.Context, peer string, msg Mensagem) error {
+	path, ok := tipoParaPath[msg.Tipo]
+	if !ok {
+		return fmt.Errorf("transporte HTTP: tipo de mensagem desconhecido: %s", msg.Tipo)
+	}
+	url := fmt.Sprintf("http://%s%s", peer, path)
+
+	corpo, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar JSON: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(corpo))
+	if err != nil {
+		return fmt.Errorf("erro ao montar request para %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao enviar POST para %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("erro: %s respondeu com status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *HTTPTransport) Register(h Handler) {
+	t.router.POST(h.Path, func(c *gin.Context) {
+		var msg Mensagem
+		if err := c.BindJSON(&msg); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reply, err := h.Fn(msg)
+		if err != nil {
+			if errors.Is(err, ErrFilaCheia) {
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "tipo": msg.Tipo, "reply": reply})
+	})
+}
+
+// --- Transporte gRPC ---
+//
+// Descoped: este transporte é gRPC/HTTP2 de verdade, mas serializa Mensagem
+// como JSON (jsonCodec abaixo) em vez de wire-format protobuf. O esquema em
+// proto/transport.proto documenta o formato para uma geração futura dos
+// stubs com protoc + protoc-gen-go/protoc-gen-go-grpc (não disponíveis no
+// ambiente de build atual), mas nenhum código deste arquivo depende dele
+// hoje; "sdav2.Transport/Send" é registrado manualmente com um
+// grpc.ServiceDesc que despacha direto para Mensagem via JSON.
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// transportServer é o tipo de interface exigido por grpc.ServiceDesc.HandlerType;
+// como o serviço é registrado manualmente (sem stubs gerados por protoc),
+// ele não precisa declarar nenhum método.
+type transportServer interface{}
+
+type GRPCTransport struct {
+	mu       sync.Mutex
+	conns    map[string]*grpc.ClientConn
+	handlers map[string]func(Mensagem) (Mensagem, error)
+	server   *grpc.Server
+}
+
+func NewGRPCTransport(listenAddr string) (*GRPCTransport, error) {
+	t := &GRPCTransport{
+		conns:    make(map[string]*grpc.ClientConn),
+		handlers: make(map[string]func(Mensagem) (Mensagem, error)),
+	}
+
+	lis, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao escutar em %s para gRPC: %w", listenAddr, err)
+	}
+
+	t.server = grpc.NewServer()
+	t.server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "sdav2.Transport",
+		HandlerType: (*transportServer)(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "Send", Handler: t.sendRPC},
+		},
+		Metadata: "transport.proto",
+	}, t)
+
+	go func() {
+		fmt.Printf("[TRANSPORT] servidor gRPC escutando em %s\n", listenAddr)
+		if err := t.server.Serve(lis); err != nil {
+			fmt.Printf("ERRO: servidor gRPC encerrou: %v\n", err)
+		}
+	}()
+
+	return t, nil
+}
+
+func (t *GRPCTransport) sendRPC(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Mensagem)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	self := srv.(*GRPCTransport)
+	if interceptor == nil {
+		return self.despachar(*in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/sdav2.Transport/Send"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return self.despachar(*req.(*Mensagem))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func (t *GRPCTransport) despachar(msg Mensagem) (*Mensagem, error) {
+	t.mu.Lock()
+	fn, ok := t.handlers[msg.Tipo]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("transporte gRPC: nenhum handler registrado para o tipo %q", msg.Tipo)
+	}
+	reply, err := fn(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+func (t *GRPCTransport) conexaoPara(peer string) (*grpc.ClientConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if conn, ok := t.conns[peer]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(peer, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultCallOptions(grpc.CallContentSubtype("json")))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao conectar via gRPC em %s: %w", peer, err)
+	}
+	t.conns[peer] = conn
+	return conn, nil
+}
+
+func (t *GRPCTransport) Send(ctx context.Context, peer string, msg Mensagem) error {
+	conn, err := t.conexaoPara(peer)
+	if err != nil {
+		return err
+	}
+	var reply Mensagem
+	return conn.Invoke(ctx, "/sdav2.Transport/Send", &msg, &reply)
+}
+
+func (t *GRPCTransport) Register(h Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[h.Tipo] = h.Fn
+}